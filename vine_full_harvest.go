@@ -2,35 +2,67 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"embed"
+	"encoding"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
+	"math/rand"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // Flags
 var (
-	inputDir    = flag.String("inputDir", "vine_tweets", "Directory OR s3://bucket/prefix containing Vine-Tweets text files")
-	outDir      = flag.String("outDir", "vine_archive_harvest", "Output root directory")
-	baseProfile = flag.String("baseProfile", "https://archive.vine.co/profiles", "Base URL for profile JSON (no trailing slash)")
-	basePost    = flag.String("basePost", "https://archive.vine.co/posts", "Base URL for post JSON (no trailing slash)")
-	workers     = flag.Int("workers", 128, "Number of concurrent workers")
-	download    = flag.Bool("download", false, "Download media files from vines.s3.amazonaws.com")
+	inputDir         = flag.String("inputDir", "vine_tweets", "Directory OR s3://bucket/prefix containing Vine-Tweets text files")
+	outDir           = flag.String("outDir", "vine_archive_harvest", "Output root directory")
+	baseProfile      = flag.String("baseProfile", "https://archive.vine.co/profiles", "Base URL for profile JSON (no trailing slash)")
+	basePost         = flag.String("basePost", "https://archive.vine.co/posts", "Base URL for post JSON (no trailing slash)")
+	workers          = flag.Int("workers", 128, "Number of concurrent workers")
+	download         = flag.Bool("download", false, "Download media files from vines.s3.amazonaws.com")
+	verify           = flag.Bool("verify", false, "On resume, re-check the SHA-1 of already-downloaded media instead of trusting its presence")
+	silentFlag       = flag.Bool("silent", false, "Suppress all non-error log output")
+	noProgress       = flag.Bool("no-progress", false, "Disable the live progress bars but keep normal logging")
+	statsJSONPath    = flag.String("stats-json", "", "Write a machine-readable run summary (counts, bytes, retries) to this path on exit")
+	dryRun           = flag.Bool("dry-run", false, "Log intended profile/post/media writes instead of performing them")
+	multipartMin     = flag.Int64("s3-multipart-threshold", 8*1024*1024, "Media at or above this size (bytes) is uploaded to an s3:// outDir via multipart upload")
+	rpsProfile       = flag.Float64("rps-profile", 200, "Target requests/sec against archive.vine.co (profiles + posts)")
+	rpsMedia         = flag.Float64("rps-media", 200, "Target requests/sec against vines.s3.amazonaws.com (media downloads)")
+	burst            = flag.Int("burst", 50, "Token bucket burst size, per host")
+	maxAttempts      = flag.Int("max-retries", 6, "Max attempts per request before giving up, on connection errors, 429s, and 5xxs")
+	transcode        = flag.Bool("transcode", false, "After downloading each .mp4, transcode it to normalized H.264 + WebM/VP9 and extract a thumbnail")
+	ffmpegPath       = flag.String("ffmpeg-path", "ffmpeg", "Path to the ffmpeg binary (ffprobe is looked up alongside it)")
+	transcodeWorkers = flag.Int("transcode-workers", 2, "Size of the CPU-bound transcode worker pool, separate from --workers")
 )
 
 // HTTP client (shared)
@@ -43,9 +75,161 @@ var httpClient = &http.Client{
 	},
 }
 
-// global rate limiter
-// Tweak this if you want to push harder, e.g. time.Second/10 ≈ 10 req/s
-var rateLimiter = time.Tick(time.Second / 200)
+// ------------------------ per-host adaptive rate limiting ------------------------
+
+// tokenBucket is a per-host rate limiter whose effective rate backs off
+// (halves) on 429/5xx responses and creeps back toward its configured
+// target after a run of consecutive successes (AIMD), instead of a single
+// fixed global rate that either bans us or leaves throughput on the table.
+type tokenBucket struct {
+	mu        sync.Mutex
+	tokens    float64
+	burst     float64
+	target    float64 // configured ceiling, from --rps-profile/--rps-media
+	rate      float64 // current effective rate, <= target
+	last      time.Time
+	successes int
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{tokens: burst, burst: burst, target: rate, rate: rate, last: time.Now()}
+}
+
+// wait blocks until a token is available, refilling at the current rate.
+func (b *tokenBucket) wait() {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+}
+
+// throttle halves the effective rate (down to a 1 rps floor) after a
+// 429/5xx, and resets the success streak that drives recovery.
+func (b *tokenBucket) throttle() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate /= 2
+	if b.rate < 1 {
+		b.rate = 1
+	}
+	b.successes = 0
+}
+
+// restoreAfter is how many consecutive successes it takes to nudge the
+// rate back up by 10% of its target, once it's been throttled down.
+const restoreAfter = 50
+
+func (b *tokenBucket) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rate >= b.target {
+		return
+	}
+	b.successes++
+	if b.successes >= restoreAfter {
+		b.successes = 0
+		b.rate += b.target * 0.1
+		if b.rate > b.target {
+			b.rate = b.target
+		}
+	}
+}
+
+// hostLimiters holds one tokenBucket per remote host, created lazily so
+// archive.vine.co and vines.s3.amazonaws.com (and anything else a --baseProfile
+// or --basePost override points at) each get their own independent budget.
+var hostLimiters = struct {
+	mu sync.Mutex
+	m  map[string]*tokenBucket
+}{m: make(map[string]*tokenBucket)}
+
+func limiterFor(host string) *tokenBucket {
+	hostLimiters.mu.Lock()
+	defer hostLimiters.mu.Unlock()
+	if b, ok := hostLimiters.m[host]; ok {
+		return b
+	}
+	rate := *rpsProfile
+	if host == "vines.s3.amazonaws.com" {
+		rate = *rpsMedia
+	}
+	b := newTokenBucket(rate, float64(*burst))
+	hostLimiters.m[host] = b
+	return b
+}
+
+// backoffDelay is exponential backoff with jitter, capped at 30s.
+func backoffDelay(attempt int) time.Duration {
+	const base = 250 * time.Millisecond
+	const max = 30 * time.Second
+	d := base << attempt
+	if d <= 0 || d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// doWithRetry sends req, applying the per-host token bucket, exponential
+// backoff with jitter, and Retry-After on 429/5xx. It halves the host's
+// rate on throttling responses and restores it gradually on success.
+// Non-retryable non-200s (4xx other than 429) are returned immediately.
+func doWithRetry(req *http.Request, stats *RunStats) (*http.Response, error) {
+	bucket := limiterFor(req.URL.Host)
+
+	var lastErr error
+	for attempt := 0; attempt < *maxAttempts; attempt++ {
+		bucket.wait()
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			stats.addRetry()
+			time.Sleep(backoffDelay(attempt))
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
+			bucket.recordSuccess()
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		stats.addStatus(resp.StatusCode)
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, fmt.Errorf("HTTP %d for %s", resp.StatusCode, req.URL.String())
+		}
+
+		bucket.throttle()
+		stats.addRetry()
+		lastErr = fmt.Errorf("HTTP %d for %s", resp.StatusCode, req.URL.String())
+
+		delay := backoffDelay(attempt)
+		if ra := resp.Header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil {
+				delay = time.Duration(secs) * time.Second
+			}
+		}
+		time.Sleep(delay)
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", *maxAttempts, lastErr)
+}
 
 // downloadedMedia keeps us from downloading the same file more than once.
 var downloadedMedia = struct {
@@ -56,57 +240,163 @@ var downloadedMedia = struct {
 // regex to extract vine.co/v/<id> slugs
 var vineURLRe = regexp.MustCompile(`vine\.co\/v\/([A-Za-z0-9]+)`)
 
+// global job queue, populated in main and consulted throughout the pipeline
+// so a Ctrl-C'd run can pick up where it left off instead of redoing work.
+var jobQueue *JobQueue
+
+// stateDir is where the job queue journal and in-progress ".tmp" media
+// downloads live. Always local disk, even when outDir is an s3:// bucket,
+// set once in main before any worker starts.
+var stateDir string
+
+// outSink is where profiles/<uid>.json, posts/<uid>/<pid>.json, and
+// media/** end up: a local directory by default, or an s3://bucket/prefix
+// when outDir uses that scheme.
+var outSink Sink
+
+// Key prefixes within outSink. These used to be local subdirectories
+// (profilesDir/postsRoot/mediaRoot); now they're just virtual prefixes
+// since outSink may not be backed by a filesystem at all.
+const (
+	profilesPrefix = "profiles"
+	postsPrefix    = "posts"
+	mediaPrefix    = "media"
+)
+
+// transcodeJobs feeds the CPU-bound transcode worker pool (started in main
+// when --transcode is set), kept separate from the HTTP --workers pool so
+// ffmpeg runs don't starve network fetches.
+var transcodeJobs chan transcodeJob
+var transcodeWG sync.WaitGroup
+
+// shuttingDown is flipped by the SIGINT/SIGTERM handler. Dispatch loops poll
+// it between jobs so a Ctrl-C drains in-flight work instead of being killed
+// mid-write.
+var shuttingDown int32
+
+func shouldStop() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// logInfo is for progress/status lines that --silent should suppress.
+// Actual errors and fatal conditions keep using the log package directly.
+func logInfo(format string, args ...interface{}) {
+	if *silentFlag {
+		return
+	}
+	log.Printf(format, args...)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		runRender(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
-	profilesDir := filepath.Join(*outDir, "profiles")
-	postsRoot := filepath.Join(*outDir, "posts")
-	mediaRoot := filepath.Join(*outDir, "media")
+	stats := newRunStats()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		logInfo("received interrupt, draining in-flight work before exiting...")
+		atomic.StoreInt32(&shuttingDown, 1)
+	}()
+
+	// The state journal always lives on local disk, even when outDir is an
+	// s3:// bucket: resumability bookkeeping is this machine's business, not
+	// the archive's.
+	stateDir = filepath.Join(".", "state")
+	if !strings.HasPrefix(*outDir, "s3://") {
+		stateDir = filepath.Join(*outDir, "state")
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		log.Fatalf("MkdirAll stateDir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(stateDir, "tmp"), 0755); err != nil {
+		log.Fatalf("MkdirAll stateDir/tmp: %v", err)
+	}
 
-	if err := os.MkdirAll(profilesDir, 0755); err != nil {
-		log.Fatalf("MkdirAll profilesDir: %v", err)
+	var err error
+	outSink, err = newSink(*outDir)
+	if err != nil {
+		log.Fatalf("newSink: %v", err)
 	}
-	if err := os.MkdirAll(postsRoot, 0755); err != nil {
-		log.Fatalf("MkdirAll postsRoot: %v", err)
+
+	jobQueue, err = loadJobQueue(filepath.Join(stateDir, "journal.json"))
+	if err != nil {
+		log.Fatalf("loadJobQueue: %v", err)
 	}
-	if *download {
-		if err := os.MkdirAll(mediaRoot, 0755); err != nil {
-			log.Fatalf("MkdirAll mediaRoot: %v", err)
+	defer jobQueue.Flush()
+	jobQueue.startAutoFlush(5 * time.Second)
+
+	if *transcode {
+		transcodeJobs = make(chan transcodeJob, *workers)
+		for i := 0; i < *transcodeWorkers; i++ {
+			transcodeWG.Add(1)
+			go transcodeWorker(i, stats)
 		}
 	}
 
+	// Reconcile: anything left pending/in-flight/failed from a previous run
+	// gets folded back into this run's worklists before we scan for new input.
+	resumedSlugs := jobQueue.Unfinished(kindSlug)
+	resumedUsers := jobQueue.Unfinished(kindUser)
+	resumedMedia := jobQueue.Unfinished(kindMedia)
+	resumedMeta := jobQueue.UnfinishedMeta(kindMedia)
+	if len(resumedSlugs) > 0 || len(resumedUsers) > 0 {
+		logInfo("Resuming: %d slugs and %d users left over from a previous run\n", len(resumedSlugs), len(resumedUsers))
+	}
+
 	// Step 1: scan vine_tweets (local or s3://) for vine.co/v/... slugs
-	log.Printf("=== Scanning %s for Vine video URLs ===\n", *inputDir)
+	logInfo("=== Scanning %s for Vine video URLs ===\n", *inputDir)
 	slugs, err := collectVineSlugs(*inputDir)
 	if err != nil {
 		log.Fatalf("collectVineSlugs: %v", err)
 	}
+	slugs = mergeUnique(slugs, resumedSlugs)
 	if len(slugs) == 0 {
 		log.Fatalf("No Vine video URLs found in %s", *inputDir)
 	}
-	log.Printf("Collected %d unique Vine video IDs from %s\n", len(slugs), *inputDir)
+	logInfo("Collected %d unique Vine video IDs from %s\n", len(slugs), *inputDir)
+	for _, s := range slugs {
+		jobQueue.MarkPending(kindSlug, s)
+	}
 
 	// Step 2: from those slugs, fetch posts + discover user IDs
-	log.Println("=== Seeding posts and discovering users from slugs ===")
-	userIDs, err := fetchUsersFromSlugs(slugs, postsRoot)
+	logInfo("=== Seeding posts and discovering users from slugs ===")
+	seedBar := newProgressBar("seed fetch", int64(len(slugs)))
+	userIDs, err := fetchUsersFromSlugs(slugs, stats, seedBar)
+	seedBar.stop()
 	if err != nil {
 		log.Fatalf("fetchUsersFromSlugs: %v", err)
 	}
+	userIDs = mergeUnique(userIDs, resumedUsers)
 	if len(userIDs) == 0 {
 		log.Fatalf("No user IDs discovered from Vine tweets")
 	}
-	log.Printf("Discovered %d unique user IDs from vine_tweets\n", len(userIDs))
+	logInfo("Discovered %d unique user IDs from vine_tweets\n", len(userIDs))
+	for _, uid := range userIDs {
+		jobQueue.MarkPending(kindUser, uid)
+	}
 
 	// Save discovered user IDs
-	profilesJSONPath := filepath.Join(*outDir, "profiles.json")
-	if err := writeJSONFile(profilesJSONPath, userIDs); err != nil {
-		log.Printf("Warning: failed to write %s: %v\n", profilesJSONPath, err)
+	if err := writeSinkJSON(context.Background(), outSink, "profiles.json", userIDs); err != nil {
+		log.Printf("Warning: failed to write profiles.json: %v\n", err)
 	} else {
-		log.Printf("Wrote discovered user IDs to %s\n", profilesJSONPath)
+		logInfo("Wrote discovered user IDs to profiles.json\n")
 	}
 
 	// Step 3: harvest profiles + posts for each user
-	log.Println("=== Harvesting profiles + posts per user ===")
+	logInfo("=== Harvesting profiles + posts per user ===")
+
+	harvestBar := newProgressBar("per-user harvest", int64(len(userIDs)))
 
 	jobs := make(chan string, *workers*2)
 	var wg sync.WaitGroup
@@ -116,20 +406,115 @@ func main() {
 		go func(workerID int) {
 			defer wg.Done()
 			for uid := range jobs {
-				if err := processUser(uid, profilesDir, postsRoot, mediaRoot, workerID); err != nil {
+				jobQueue.MarkInFlight(kindUser, uid)
+				if err := processUser(uid, workerID, stats); err != nil {
 					log.Printf("[worker %d] user %s: %v\n", workerID, uid, err)
+					jobQueue.MarkFailed(kindUser, uid, err)
+					stats.addUserSkipped()
+					harvestBar.incDone()
+					continue
 				}
+				jobQueue.MarkDone(kindUser, uid)
+				stats.addUserDone()
+				harvestBar.incDone()
 			}
 		}(i)
 	}
 
 	for _, uid := range userIDs {
+		if shouldStop() {
+			break
+		}
 		jobs <- uid
 	}
 	close(jobs)
 	wg.Wait()
+	harvestBar.stop()
+
+	// Step 4: retry any media left pending/in-flight/failed from a previous
+	// run. processUser above only ever attempts media for a post it just
+	// fetched fresh — a post whose JSON was already written by an earlier run
+	// is skipped outright (see the postKey exists guard in processUser), so
+	// without this pass a media download that failed last time would never
+	// be revisited. downloadMedia only needs the media URL itself, so these
+	// can be retried directly without re-walking posts.
+	if len(resumedMedia) > 0 {
+		logInfo("=== Resuming %d media downloads left over from a previous run ===\n", len(resumedMedia))
+		mediaBar := newProgressBar("resumed media", int64(len(resumedMedia)))
+
+		mediaJobs := make(chan string, *workers*2)
+		var mediaWG sync.WaitGroup
+		for i := 0; i < *workers; i++ {
+			mediaWG.Add(1)
+			go func(workerID int) {
+				defer mediaWG.Done()
+				for mu := range mediaJobs {
+					jobQueue.MarkInFlight(kindMedia, mu)
+					if err := downloadMedia(mu, stats); err != nil {
+						log.Printf("[media worker %d] %s: %v\n", workerID, mu, err)
+						jobQueue.MarkFailed(kindMedia, mu, err)
+						mediaBar.incDone()
+						continue
+					}
+					jobQueue.MarkDone(kindMedia, mu)
+					if transcodeJobs != nil && strings.HasSuffix(strings.ToLower(mu), ".mp4") {
+						if meta := resumedMeta[mu]; meta["userID"] != "" {
+							transcodeJobs <- transcodeJob{mediaURL: mu, userID: meta["userID"], postID: meta["postID"]}
+						} else {
+							log.Printf("[media worker %d] %s: resumed with no owning user/post on record, skipping transcode\n", workerID, mu)
+						}
+					}
+					mediaBar.incDone()
+				}
+			}(i)
+		}
+		for _, mu := range resumedMedia {
+			if shouldStop() {
+				break
+			}
+			mediaJobs <- mu
+		}
+		close(mediaJobs)
+		mediaWG.Wait()
+		mediaBar.stop()
+	}
+
+	if transcodeJobs != nil {
+		logInfo("=== Draining transcode queue ===")
+		close(transcodeJobs)
+		transcodeWG.Wait()
+	}
+
+	jobQueue.Flush()
+	stats.finish()
+	logInfo("All done.")
+	fmt.Println(stats.Summary())
+	if *statsJSONPath != "" {
+		if err := writeJSONFile(*statsJSONPath, stats.Snapshot()); err != nil {
+			log.Printf("write stats-json %s: %v\n", *statsJSONPath, err)
+		}
+	}
+	if shouldStop() {
+		os.Exit(130)
+	}
+}
 
-	log.Println("All done.")
+// mergeUnique appends b's elements onto a, skipping any already present in a.
+func mergeUnique(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]struct{}, len(a))
+	for _, v := range a {
+		seen[v] = struct{}{}
+	}
+	for _, v := range b {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			a = append(a, v)
+		}
+	}
+	return a
 }
 
 // ------------------------ Step 1: scan vine_tweets for slugs ------------------------
@@ -190,20 +575,11 @@ func collectVineSlugsFromFS(root string) ([]string, error) {
 	return slugs, nil
 }
 
-// collectVineSlugsFromS3 reads objects from an R2/S3 bucket and scans them for vine.co/v/... slugs.
-// The root parameter must look like: s3://bucket-name/prefix
-func collectVineSlugsFromS3(s3URL string) ([]string, error) {
-	u, err := url.Parse(s3URL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid s3 URL %q: %w", s3URL, err)
-	}
-	bucket := u.Host
-	prefix := strings.TrimLeft(u.Path, "/")
-
-	if bucket == "" {
-		return nil, fmt.Errorf("s3 URL must be like s3://bucket/prefix")
-	}
-
+// newR2Client builds an S3 client pointed at the R2 (or other S3-compatible)
+// endpoint configured via R2_ENDPOINT/R2_ACCESS_KEY_ID/R2_SECRET_ACCESS_KEY.
+// Shared by collectVineSlugsFromS3 (reading s3:// input) and s3Sink (writing
+// s3:// output) so both sides of the pipeline speak to R2 the same way.
+func newR2Client(ctx context.Context) (*s3.Client, error) {
 	endpoint := os.Getenv("R2_ENDPOINT")
 	if endpoint == "" {
 		return nil, fmt.Errorf("R2_ENDPOINT env var is required for S3/R2 mode")
@@ -214,12 +590,7 @@ func collectVineSlugsFromS3(s3URL string) ([]string, error) {
 		return nil, fmt.Errorf("R2_ACCESS_KEY_ID and R2_SECRET_ACCESS_KEY env vars are required for S3/R2 mode")
 	}
 
-	log.Printf("Using R2 bucket=%s prefix=%s endpoint=%s\n", bucket, prefix, endpoint)
-
-	ctx := context.Background()
-
-	// Custom endpoint resolver for R2
-	resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string) (aws.Endpoint, error) {
+	resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		if service == s3.ServiceID {
 			return aws.Endpoint{
 				URL:               endpoint,
@@ -238,9 +609,33 @@ func collectVineSlugsFromS3(s3URL string) ([]string, error) {
 		return nil, fmt.Errorf("load AWS config for R2: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
 		o.UsePathStyle = true
-	})
+	}), nil
+}
+
+// collectVineSlugsFromS3 reads objects from an R2/S3 bucket and scans them for vine.co/v/... slugs.
+// The root parameter must look like: s3://bucket-name/prefix
+func collectVineSlugsFromS3(s3URL string) ([]string, error) {
+	u, err := url.Parse(s3URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 URL %q: %w", s3URL, err)
+	}
+	bucket := u.Host
+	prefix := strings.TrimLeft(u.Path, "/")
+
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 URL must be like s3://bucket/prefix")
+	}
+
+	ctx := context.Background()
+
+	client, err := newR2Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	logInfo("Using R2 bucket=%s prefix=%s\n", bucket, prefix)
 
 	slugSet := make(map[string]struct{})
 
@@ -261,7 +656,7 @@ func collectVineSlugsFromS3(s3URL string) ([]string, error) {
 				continue
 			}
 
-			log.Printf("Scanning R2 object: %s\n", key)
+			logInfo("Scanning R2 object: %s\n", key)
 
 			out, err := client.GetObject(ctx, &s3.GetObjectInput{
 				Bucket: aws.String(bucket),
@@ -304,12 +699,264 @@ func scanSlugsFromReader(r io.Reader, slugSet map[string]struct{}) error {
 	return scanner.Err()
 }
 
+// ------------------------ output sink: local disk or S3/R2 ------------------------
+
+// Sink abstracts "where the archive's JSON and media end up", so the rest of
+// the pipeline (writeSinkJSON, downloadMedia, ...) doesn't care whether
+// outDir is a local directory or an s3://bucket/prefix backed by R2 — the
+// same symmetry --inputDir already has.
+type Sink interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// newSink picks a Sink implementation from outDir, the same way
+// collectVineSlugs picks an input reader from inputDir.
+func newSink(root string) (Sink, error) {
+	var sink Sink
+	var err error
+	if strings.HasPrefix(root, "s3://") {
+		sink, err = newS3Sink(root)
+	} else {
+		sink = &localSink{root: root}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if *dryRun {
+		sink = &dryRunSink{inner: sink}
+	}
+	return sink, nil
+}
+
+// localSink writes under a root directory on disk, using the same
+// write-to-tmp-then-rename pattern the rest of this tool uses everywhere
+// else to avoid leaving truncated files behind on a crash.
+type localSink struct {
+	root string
+}
+
+func (s *localSink) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *localSink) Exists(ctx context.Context, key string) (bool, error) {
+	return fileExists(s.path(key)), nil
+}
+
+func (s *localSink) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s *localSink) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// s3Sink writes into an S3/R2 bucket+prefix, reusing the same client
+// construction as the s3:// --inputDir path. Objects at or above
+// multipartMin are uploaded in parts.
+type s3Sink struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3Sink(root string) (*s3Sink, error) {
+	u, err := url.Parse(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 URL %q: %w", root, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("s3 outDir must be like s3://bucket/prefix")
+	}
+
+	client, err := newR2Client(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Sink{
+		client: client,
+		bucket: u.Host,
+		prefix: strings.TrimLeft(u.Path, "/"),
+	}, nil
+}
+
+func (s *s3Sink) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return strings.TrimRight(s.prefix, "/") + "/" + key
+}
+
+func (s *s3Sink) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err == nil {
+		return true, nil
+	}
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s *s3Sink) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Put spools r to a temp file rather than buffering it in memory: the
+// multipart path below needs to seek to read each part, and with many
+// concurrent uploads in flight an in-memory buffer per object would risk
+// the exact memory blowup multipart upload exists to avoid.
+func (s *s3Sink) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	tmp, err := os.CreateTemp("", "sink-put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+
+	if size >= *multipartMin {
+		return s.putMultipart(ctx, key, tmp, size, contentType)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.objectKey(key)),
+		Body:        tmp,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+func (s *s3Sink) putMultipart(ctx context.Context, key string, f *os.File, size int64, contentType string) error {
+	fullKey := s.objectKey(key)
+
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(fullKey),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("create multipart upload: %w", err)
+	}
+
+	const partSize = 8 * 1024 * 1024
+	var parts []types.CompletedPart
+	for offset, partNum := int64(0), int32(1); offset < size; offset, partNum = offset+partSize, partNum+1 {
+		n := int64(partSize)
+		if offset+n > size {
+			n = size - offset
+		}
+		out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(fullKey),
+			UploadId:   created.UploadId,
+			PartNumber: aws.Int32(partNum),
+			Body:       io.NewSectionReader(f, offset, n),
+		})
+		if err != nil {
+			s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket: aws.String(s.bucket), Key: aws.String(fullKey), UploadId: created.UploadId,
+			})
+			return fmt.Errorf("upload part %d: %w", partNum, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(partNum)})
+	}
+
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(fullKey),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
+// dryRunSink wraps another Sink and logs intended writes instead of
+// performing them, for --dry-run.
+type dryRunSink struct {
+	inner Sink
+}
+
+func (d *dryRunSink) Exists(ctx context.Context, key string) (bool, error) {
+	return d.inner.Exists(ctx, key)
+}
+
+func (d *dryRunSink) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return d.inner.Get(ctx, key)
+}
+
+func (d *dryRunSink) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	n, _ := io.Copy(io.Discard, r)
+	logInfo("[dry-run] would put %q (%s, %d bytes)\n", key, contentType, n)
+	return nil
+}
+
+// writeSinkJSON marshals v as indented JSON and puts it at key.
+func writeSinkJSON(ctx context.Context, sink Sink, key string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return sink.Put(ctx, key, bytes.NewReader(data), "application/json")
+}
+
+// contentTypeForKey guesses a Content-Type from a key's file extension,
+// falling back to a generic binary type, mirroring the extension-based
+// Content-Type logic in the drone-s3-sync plugin.
+func contentTypeForKey(key string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
 // ------------------------ Step 2: from slugs → posts + user IDs ------------------------
 
-func fetchUsersFromSlugs(slugs []string, postsRoot string) ([]string, error) {
+func fetchUsersFromSlugs(slugs []string, stats *RunStats, bar *progressBar) ([]string, error) {
 	userSet := make(map[string]struct{})
 	var userMu sync.Mutex
 
+	ctx := context.Background()
+
 	jobs := make(chan string, *workers*2)
 	var wg sync.WaitGroup
 
@@ -318,11 +965,14 @@ func fetchUsersFromSlugs(slugs []string, postsRoot string) ([]string, error) {
 		go func(workerID int) {
 			defer wg.Done()
 			for slug := range jobs {
+				jobQueue.MarkInFlight(kindSlug, slug)
 				postURL := fmt.Sprintf("%s/%s.json", strings.TrimRight(*basePost, "/"), url.PathEscape(slug))
 
-				postData, err := fetchJSONMap(postURL)
+				postData, err := fetchJSONMap(postURL, stats)
 				if err != nil {
 					log.Printf("[seed worker %d] post slug %s: %v\n", workerID, slug, err)
+					jobQueue.MarkFailed(kindSlug, slug, err)
+					bar.incDone()
 					continue
 				}
 
@@ -348,6 +998,8 @@ func fetchUsersFromSlugs(slugs []string, postsRoot string) ([]string, error) {
 				}
 
 				if userID == "" {
+					jobQueue.MarkFailed(kindSlug, slug, fmt.Errorf("no userId in post data"))
+					bar.incDone()
 					continue
 				}
 
@@ -359,23 +1011,23 @@ func fetchUsersFromSlugs(slugs []string, postsRoot string) ([]string, error) {
 				userMu.Unlock()
 
 				// Save this post immediately under user
-				userPostsDir := filepath.Join(postsRoot, userID)
-				if err := os.MkdirAll(userPostsDir, 0755); err != nil {
-					log.Printf("[seed worker %d] MkdirAll posts dir for %s: %v\n", workerID, userID, err)
-					continue
-				}
-				postFile := filepath.Join(userPostsDir, realID+".json")
-				if !fileExists(postFile) {
-					if err := writeJSONFile(postFile, postData); err != nil {
+				postKey := postsPrefix + "/" + userID + "/" + realID + ".json"
+				if exists, _ := outSink.Exists(ctx, postKey); !exists {
+					if err := writeSinkJSON(ctx, outSink, postKey, postData); err != nil {
 						log.Printf("[seed worker %d] write seed post %s for user %s: %v\n",
 							workerID, realID, userID, err)
 					}
 				}
+				jobQueue.MarkDone(kindSlug, slug)
+				bar.incDone()
 			}
 		}(i)
 	}
 
 	for _, slug := range slugs {
+		if shouldStop() {
+			break
+		}
 		jobs <- slug
 	}
 	close(jobs)
@@ -390,48 +1042,51 @@ func fetchUsersFromSlugs(slugs []string, postsRoot string) ([]string, error) {
 
 // ------------------------ Step 3: per-user profile + posts ------------------------
 
-func processUser(userID, profilesDir, postsRoot, mediaRoot string, workerID int) error {
-	// 1) Ensure profile JSON exists
-	profilePath := filepath.Join(profilesDir, userID+".json")
-	if !fileExists(profilePath) {
+func processUser(userID string, workerID int, stats *RunStats) error {
+	ctx := context.Background()
+	profileKey := profilesPrefix + "/" + userID + ".json"
+
+	// 1) Ensure profile JSON exists, keeping it in memory either way so a
+	// --dry-run run (which never actually Puts) doesn't need to Get back
+	// something it only pretended to write.
+	var profile map[string]interface{}
+	exists, err := outSink.Exists(ctx, profileKey)
+	if err != nil {
+		return fmt.Errorf("check profile: %w", err)
+	}
+	if exists {
+		rc, err := outSink.Get(ctx, profileKey)
+		if err != nil {
+			return fmt.Errorf("read profile JSON: %w", err)
+		}
+		err = json.NewDecoder(rc).Decode(&profile)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("decode profile JSON: %w", err)
+		}
+	} else {
 		profileURL := fmt.Sprintf("%s/%s.json", strings.TrimRight(*baseProfile, "/"), url.PathEscape(userID))
-		profile, err := fetchJSONMap(profileURL)
+		profile, err = fetchJSONMap(profileURL, stats)
 		if err != nil {
 			return fmt.Errorf("fetch profile: %w", err)
 		}
-		// Rewrite URLs in profile
 		profile = rewriteURLs(profile).(map[string]interface{})
 
-		if err := writeJSONFile(profilePath, profile); err != nil {
+		if err := writeSinkJSON(ctx, outSink, profileKey, profile); err != nil {
 			return fmt.Errorf("write profile JSON: %w", err)
 		}
 	}
 
-	// 2) Load profile to get post IDs
-	raw, err := os.ReadFile(profilePath)
-	if err != nil {
-		return fmt.Errorf("read profile JSON: %w", err)
-	}
-	var profile map[string]interface{}
-	if err := json.Unmarshal(raw, &profile); err != nil {
-		return fmt.Errorf("decode profile JSON: %w", err)
-	}
-
 	postIDs := collectPostIDsFromProfile(profile)
 	if len(postIDs) == 0 {
 		log.Printf("[worker %d] user %s: no post IDs in profile\n", workerID, userID)
 		return nil
 	}
 
-	userPostsDir := filepath.Join(postsRoot, userID)
-	if err := os.MkdirAll(userPostsDir, 0755); err != nil {
-		return fmt.Errorf("MkdirAll userPostsDir: %w", err)
-	}
-
 	for _, pid := range postIDs {
 		postURL := fmt.Sprintf("%s/%s.json", strings.TrimRight(*basePost, "/"), url.PathEscape(pid))
 
-		postData, err := fetchJSONMap(postURL)
+		postData, err := fetchJSONMap(postURL, stats)
 		if err != nil {
 			log.Printf("[worker %d] user %s post %s: %v\n", workerID, userID, pid, err)
 			continue
@@ -447,23 +1102,31 @@ func processUser(userID, profilesDir, postsRoot, mediaRoot string, workerID int)
 			realID = pid
 		}
 
-		postFile := filepath.Join(userPostsDir, realID+".json")
-		if fileExists(postFile) {
+		postKey := postsPrefix + "/" + userID + "/" + realID + ".json"
+		if exists, _ := outSink.Exists(ctx, postKey); exists {
 			continue
 		}
 
 		postData = rewriteURLs(postData).(map[string]interface{})
 
-		if err := writeJSONFile(postFile, postData); err != nil {
+		if err := writeSinkJSON(ctx, outSink, postKey, postData); err != nil {
 			log.Printf("[worker %d] user %s post %s write: %v\n", workerID, userID, realID, err)
 		}
 
 		if *download {
 			mediaURLs := collectMediaURLs(postData)
 			for _, mu := range mediaURLs {
-				if err := downloadMedia(mu, mediaRoot); err != nil {
+				jobQueue.MarkInFlight(kindMedia, mu)
+				jobQueue.SetMeta(kindMedia, mu, map[string]string{"userID": userID, "postID": realID})
+				if err := downloadMedia(mu, stats); err != nil {
 					log.Printf("[worker %d] user %s post %s media %s: %v\n",
 						workerID, userID, realID, mu, err)
+					jobQueue.MarkFailed(kindMedia, mu, err)
+					continue
+				}
+				jobQueue.MarkDone(kindMedia, mu)
+				if transcodeJobs != nil && strings.HasSuffix(strings.ToLower(mu), ".mp4") {
+					transcodeJobs <- transcodeJob{mediaURL: mu, userID: userID, postID: realID}
 				}
 			}
 		}
@@ -474,26 +1137,19 @@ func processUser(userID, profilesDir, postsRoot, mediaRoot string, workerID int)
 
 // ------------------------ HTTP + JSON helpers ------------------------
 
-func fetchJSONMap(u string) (map[string]interface{}, error) {
-	<-rateLimiter
-
+func fetchJSONMap(u string, stats *RunStats) (map[string]interface{}, error) {
 	req, err := http.NewRequest("GET", u, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", "VineFullHarvester/1.0")
 
-	resp, err := httpClient.Do(req)
+	resp, err := doWithRetry(req, stats)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(io.Discard, resp.Body)
-		return nil, fmt.Errorf("HTTP %d for %s", resp.StatusCode, u)
-	}
-
 	var out map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 		return nil, err
@@ -663,12 +1319,18 @@ func collectMediaURLs(v interface{}) []string {
 	return urls
 }
 
-func downloadMedia(rawURL, mediaRoot string) error {
+// mediaKeyForURL maps a vines.s3.amazonaws.com media URL onto its key under
+// outSink, shared by downloadMedia and the transcode pipeline so both agree
+// on where a given media file lives.
+func mediaKeyForURL(rawURL string) (string, error) {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return mediaPrefix + "/" + strings.TrimLeft(parsed.Path, "/"), nil
+}
 
+func downloadMedia(rawURL string, stats *RunStats) error {
 	// ensure we don't download the same file more than once
 	downloadedMedia.mu.Lock()
 	if _, ok := downloadedMedia.m[rawURL]; ok {
@@ -678,15 +1340,49 @@ func downloadMedia(rawURL, mediaRoot string) error {
 	downloadedMedia.m[rawURL] = struct{}{}
 	downloadedMedia.mu.Unlock()
 
-	cleanPath := strings.TrimLeft(parsed.Path, "/")
-	localPath := filepath.Join(mediaRoot, cleanPath)
+	ctx := context.Background()
+	mediaKey, err := mediaKeyForURL(rawURL)
+	if err != nil {
+		return err
+	}
+	sha1Key := mediaKey + ".sha1"
 
-	if fileExists(localPath) {
-		return nil
+	if exists, err := outSink.Exists(ctx, mediaKey); err != nil {
+		return fmt.Errorf("check media: %w", err)
+	} else if exists {
+		if !*verify {
+			return nil
+		}
+		if ok, err := verifyMediaSink(ctx, mediaKey, sha1Key); err != nil {
+			log.Printf("verify %s: %v (re-downloading)\n", mediaKey, err)
+		} else if ok {
+			return nil
+		} else {
+			log.Printf("sha1 mismatch for %s, re-downloading\n", mediaKey)
+		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
-		return err
+	// Stage the download under stateDir/tmp, keyed by a hash of the URL, so a
+	// Ctrl-C'd run can resume a partially-downloaded file instead of
+	// redoing it from byte zero: the .tmp file and its .digest sidecar
+	// (the running SHA-1's marshaled state) both survive a process restart,
+	// unlike an anonymous os.CreateTemp file.
+	tmpPath := filepath.Join(stateDir, "tmp", fmt.Sprintf("%x.tmp", sha1.Sum([]byte(rawURL))))
+	digestPath := tmpPath + ".digest"
+
+	digest := sha1.New()
+	var offset int64
+	if fi, err := os.Stat(tmpPath); err == nil {
+		if raw, err := os.ReadFile(digestPath); err == nil {
+			if err := digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(raw); err == nil {
+				offset = fi.Size()
+			}
+		}
+	}
+	if offset == 0 {
+		os.Remove(tmpPath)
+		os.Remove(digestPath)
+		digest = sha1.New()
 	}
 
 	req, err := http.NewRequest("GET", rawURL, nil)
@@ -694,29 +1390,1539 @@ func downloadMedia(rawURL, mediaRoot string) error {
 		return err
 	}
 	req.Header.Set("User-Agent", "VineFullHarvesterMedia/1.0")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 
-	resp, err := httpClient.Do(req)
+	resp, err := doWithRetry(req, stats)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		io.Copy(io.Discard, resp.Body)
-		return fmt.Errorf("media HTTP %d", resp.StatusCode)
+	flags := os.O_CREATE | os.O_RDWR
+	if offset > 0 && resp.StatusCode == http.StatusPartialContent {
+		flags |= os.O_APPEND
+	} else {
+		// Server ignored our Range request (or there was nothing to resume):
+		// start over from scratch.
+		offset = 0
+		digest = sha1.New()
+		flags |= os.O_TRUNC
 	}
 
-	tmp := localPath + ".tmp"
-	f, err := os.Create(tmp)
+	tmp, err := os.OpenFile(tmpPath, flags, 0644)
 	if err != nil {
 		return err
 	}
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		f.Close()
+	defer tmp.Close()
+
+	written, err := io.Copy(io.MultiWriter(tmp, digest), resp.Body)
+	if err != nil {
+		if raw, merr := digest.(encoding.BinaryMarshaler).MarshalBinary(); merr == nil {
+			os.WriteFile(digestPath, raw, 0644)
+		}
 		return err
 	}
-	if err := f.Close(); err != nil {
+	n := offset + written
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := outSink.Put(ctx, mediaKey, tmp, contentTypeForKey(mediaKey)); err != nil {
+		return err
+	}
+	stats.addBytes(n)
+	os.Remove(tmpPath)
+	os.Remove(digestPath)
+
+	sha1Body := hex.EncodeToString(digest.Sum(nil)) + "\n"
+	return outSink.Put(ctx, sha1Key, strings.NewReader(sha1Body), "text/plain")
+}
+
+// verifyMediaSink recomputes the SHA-1 of an already-downloaded media object
+// and compares it against its ".sha1" sidecar, written the last time it was
+// downloaded successfully.
+func verifyMediaSink(ctx context.Context, mediaKey, sha1Key string) (bool, error) {
+	sidecar, err := outSink.Get(ctx, sha1Key)
+	if err != nil {
+		return false, fmt.Errorf("missing sha1 sidecar: %w", err)
+	}
+	wantRaw, err := io.ReadAll(sidecar)
+	sidecar.Close()
+	if err != nil {
+		return false, err
+	}
+	want := strings.TrimSpace(string(wantRaw))
+
+	r, err := outSink.Get(ctx, mediaKey)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	digest := sha1.New()
+	if _, err := io.Copy(digest, r); err != nil {
+		return false, err
+	}
+	got := hex.EncodeToString(digest.Sum(nil))
+	return got == want, nil
+}
+
+// ------------------------ optional ffmpeg transcode + thumbnail pipeline ------------------------
+
+// transcodeJob is one unit of CPU-bound work for the transcode worker pool:
+// normalize an already-downloaded .mp4 into a couple of web-friendly
+// variants plus a thumbnail, and record it all in that post's manifest.
+type transcodeJob struct {
+	mediaURL string
+	userID   string
+	postID   string
+}
+
+// mediaVariant is one transcoded output of a media file, as recorded in its
+// post's manifest.json.
+type mediaVariant struct {
+	Kind string `json:"kind"` // "h264", "vp9", or "thumbnail"
+	Key  string `json:"key"`
+}
+
+// postManifest links a post's original Vine media to its transcoded
+// variants, so a downstream viewer doesn't have to re-probe the files.
+type postManifest struct {
+	OriginalURL string         `json:"originalUrl"`
+	MediaKey    string         `json:"mediaKey"`
+	SHA1        string         `json:"sha1"`
+	DurationSec float64        `json:"durationSeconds"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Transcodes  []mediaVariant `json:"transcodes"`
+}
+
+func transcodeWorker(workerID int, stats *RunStats) {
+	defer transcodeWG.Done()
+	for job := range transcodeJobs {
+		if shouldStop() {
+			continue
+		}
+		if err := transcodeMedia(job); err != nil {
+			log.Printf("[transcode worker %d] user %s post %s: %v\n", workerID, job.userID, job.postID, err)
+		}
+	}
+}
+
+// transcodeMedia pulls the original media for job out of outSink into a
+// local temp file (ffmpeg needs a real path, even when outSink is an S3/R2
+// bucket), runs it through ffmpeg/ffprobe, and puts the variants back into
+// outSink under media/<path>.transcoded/ and the manifest at
+// posts/<uid>/<pid>.manifest.json.
+func transcodeMedia(job transcodeJob) error {
+	ctx := context.Background()
+	mediaKey, err := mediaKeyForURL(job.mediaURL)
+	if err != nil {
+		return err
+	}
+
+	rc, err := outSink.Get(ctx, mediaKey)
+	if err != nil {
+		return fmt.Errorf("get original media: %w", err)
+	}
+	tmpIn, err := os.CreateTemp("", "vine-transcode-in-*.mp4")
+	if err != nil {
+		rc.Close()
+		return err
+	}
+	defer os.Remove(tmpIn.Name())
+	digest := sha1.New()
+	_, err = io.Copy(io.MultiWriter(tmpIn, digest), rc)
+	rc.Close()
+	closeErr := tmpIn.Close()
+	if err != nil {
+		return fmt.Errorf("copy original media to temp file: %w", err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	duration, width, height, err := probeMedia(ctx, tmpIn.Name())
+	if err != nil {
+		log.Printf("ffprobe %s: %v (continuing without duration/resolution)\n", mediaKey, err)
+	}
+
+	outDir := mediaKey + ".transcoded"
+	manifest := postManifest{
+		OriginalURL: job.mediaURL,
+		MediaKey:    mediaKey,
+		SHA1:        hex.EncodeToString(digest.Sum(nil)),
+		DurationSec: duration,
+		Width:       width,
+		Height:      height,
+	}
+
+	variants := []struct {
+		kind string
+		key  string
+		args func(in, out string) []string
+	}{
+		{"h264", outDir + "/h264.mp4", func(in, out string) []string {
+			return []string{"-y", "-i", in, "-c:v", "libx264", "-c:a", "aac", "-movflags", "+faststart", out}
+		}},
+		{"vp9", outDir + "/vp9.webm", func(in, out string) []string {
+			return []string{"-y", "-i", in, "-c:v", "libvpx-vp9", "-c:a", "libopus", out}
+		}},
+		{"thumbnail", outDir + "/thumb.jpg", func(in, out string) []string {
+			return []string{"-y", "-ss", "0.5", "-i", in, "-frames:v", "1", out}
+		}},
+	}
+
+	for _, v := range variants {
+		tmpOut := tmpIn.Name() + "." + v.kind
+		cmd := exec.CommandContext(ctx, *ffmpegPath, v.args(tmpIn.Name(), tmpOut)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("ffmpeg %s for %s: %v: %s\n", v.kind, mediaKey, err, bytes.TrimSpace(out))
+			continue
+		}
+		if err := putFile(ctx, v.key, tmpOut); err != nil {
+			log.Printf("put transcoded %s for %s: %v\n", v.kind, mediaKey, err)
+			os.Remove(tmpOut)
+			continue
+		}
+		os.Remove(tmpOut)
+		manifest.Transcodes = append(manifest.Transcodes, mediaVariant{Kind: v.kind, Key: v.key})
+	}
+
+	manifestKey := postsPrefix + "/" + job.userID + "/" + job.postID + ".manifest.json"
+	return writeSinkJSON(ctx, outSink, manifestKey, manifest)
+}
+
+// putFile streams a local file into outSink at key, for transcode outputs
+// that ffmpeg can only write to a real path.
+func putFile(ctx context.Context, key, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return outSink.Put(ctx, key, f, contentTypeForKey(key))
+}
+
+// probeMedia shells out to ffprobe (assumed to live alongside ffmpeg) to
+// read a media file's duration and resolution for the manifest.
+func probeMedia(ctx context.Context, path string) (durationSec float64, width, height int, err error) {
+	ffprobePath := filepath.Join(filepath.Dir(*ffmpegPath), "ffprobe")
+	cmd := exec.CommandContext(ctx, ffprobePath, "-v", "error",
+		"-show_entries", "format=duration:stream=width,height", "-of", "json", path)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return 0, 0, 0, err
+	}
+	durationSec, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	for _, s := range probe.Streams {
+		if s.Width > 0 && s.Height > 0 {
+			width, height = s.Width, s.Height
+			break
+		}
+	}
+	return durationSec, width, height, nil
+}
+
+// ------------------------ resumable job queue / state journal ------------------------
+
+// Job states. A job starts pending, moves to inFlight while a worker holds
+// it, and ends in either done or failed. Unfinished reports every state but
+// done, so failed work (with attempts/lastErr carried forward) is resumed on
+// the next run and done work is not. A done slug or user that turns up
+// again in a later run's input is still dispatched and processed again.
+const (
+	jobPending  = "pending"
+	jobInFlight = "inflight"
+	jobDone     = "done"
+	jobFailed   = "failed"
+)
+
+// Job kinds, namespacing the queue's key space.
+const (
+	kindSlug  = "slug"
+	kindUser  = "user"
+	kindMedia = "media"
+)
+
+// jobRecord is the on-disk representation of one tracked unit of work.
+type jobRecord struct {
+	Kind      string            `json:"kind"`
+	Key       string            `json:"key"`
+	State     string            `json:"state"`
+	Attempts  int               `json:"attempts"`
+	LastErr   string            `json:"lastErr,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+// JobQueue is a persistent, mutex-guarded journal of every slug, user, and
+// media URL this tool has ever touched, so a Ctrl-C'd run can resume without
+// redoing already-completed work. It is deliberately a flat JSON file rather
+// than an embedded database, matching the rest of this tool's "plain files
+// on disk" approach to state.
+type JobQueue struct {
+	mu      sync.Mutex
+	path    string
+	records map[string]*jobRecord
+	dirty   bool
+	stop    chan struct{}
+}
+
+func jobKey(kind, key string) string {
+	return kind + ":" + key
+}
+
+// loadJobQueue reads an existing journal from path, or starts a fresh one if
+// none exists yet.
+func loadJobQueue(path string) (*JobQueue, error) {
+	q := &JobQueue{
+		path:    path,
+		records: make(map[string]*jobRecord),
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return q, nil
+		}
+		return nil, fmt.Errorf("read journal %s: %w", path, err)
+	}
+
+	var records []*jobRecord
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, fmt.Errorf("decode journal %s: %w", path, err)
+	}
+	for _, r := range records {
+		q.records[jobKey(r.Kind, r.Key)] = r
+	}
+	return q, nil
+}
+
+// Unfinished returns the keys of every record of the given kind that is not
+// in the done state, i.e. work left over from a previous run.
+func (q *JobQueue) Unfinished(kind string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var out []string
+	for _, r := range q.records {
+		if r.Kind == kind && r.State != jobDone {
+			out = append(out, r.Key)
+		}
+	}
+	return out
+}
+
+// UnfinishedMeta returns the key plus whatever SetMeta previously attached
+// to it, for every record of the given kind that is not in the done state.
+// Used to recover the userID/postID a resumed media download belongs to, so
+// it can still be routed into the transcode pipeline like a fresh one.
+func (q *JobQueue) UnfinishedMeta(kind string) map[string]map[string]string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make(map[string]map[string]string)
+	for _, r := range q.records {
+		if r.Kind == kind && r.State != jobDone {
+			out[r.Key] = r.Meta
+		}
+	}
+	return out
+}
+
+// SetMeta attaches arbitrary bookkeeping (e.g. the owning userID/postID for
+// a media URL) to an already-tracked key, carried forward across Mark*
+// calls and persisted in the journal.
+func (q *JobQueue) SetMeta(kind, key string, meta map[string]string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	k := jobKey(kind, key)
+	r, ok := q.records[k]
+	if !ok {
+		r = &jobRecord{Kind: kind, Key: key, State: jobPending}
+		q.records[k] = r
+	}
+	r.Meta = meta
+	q.dirty = true
+}
+
+// MarkPending registers a key if it isn't already tracked. It never
+// downgrades a key that's already in flight, done, or failed.
+func (q *JobQueue) MarkPending(kind, key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	k := jobKey(kind, key)
+	if _, ok := q.records[k]; ok {
+		return
+	}
+	q.records[k] = &jobRecord{Kind: kind, Key: key, State: jobPending, UpdatedAt: time.Now()}
+	q.dirty = true
+}
+
+func (q *JobQueue) MarkInFlight(kind, key string) {
+	q.setState(kind, key, jobInFlight, nil)
+}
+
+func (q *JobQueue) MarkDone(kind, key string) {
+	q.setState(kind, key, jobDone, nil)
+}
+
+func (q *JobQueue) MarkFailed(kind, key string, cause error) {
+	q.setState(kind, key, jobFailed, cause)
+}
+
+func (q *JobQueue) setState(kind, key, state string, cause error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	k := jobKey(kind, key)
+	r, ok := q.records[k]
+	if !ok {
+		r = &jobRecord{Kind: kind, Key: key}
+		q.records[k] = r
+	}
+	r.State = state
+	r.UpdatedAt = time.Now()
+	if state == jobFailed {
+		r.Attempts++
+		if cause != nil {
+			r.LastErr = cause.Error()
+		}
+	}
+	q.dirty = true
+}
+
+// startAutoFlush periodically persists the journal in the background so a
+// hard kill loses at most one interval's worth of progress, without paying
+// the cost of a full rewrite on every single state transition.
+func (q *JobQueue) startAutoFlush(interval time.Duration) {
+	q.stop = make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := q.Flush(); err != nil {
+					log.Printf("journal auto-flush: %v\n", err)
+				}
+			case <-q.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Flush writes the journal to disk if anything has changed since the last
+// flush, using the same write-to-tmp-then-rename pattern as writeJSONFile so
+// a crash mid-write never leaves a corrupt journal behind.
+func (q *JobQueue) Flush() error {
+	q.mu.Lock()
+	if !q.dirty {
+		q.mu.Unlock()
+		return nil
+	}
+	records := make([]jobRecord, 0, len(q.records))
+	for _, r := range q.records {
+		records = append(records, *r)
+	}
+	q.dirty = false
+	q.mu.Unlock()
+
+	return writeJSONFile(q.path, records)
+}
+
+// ------------------------ run stats + progress reporting ------------------------
+
+// RunStats is the atomic counter struct threaded through processUser,
+// fetchUsersFromSlugs, and downloadMedia so every stage of the pipeline
+// contributes to one end-of-run summary (and optional --stats-json dump)
+// without any of them needing to know about the others.
+type RunStats struct {
+	startTime time.Time
+	endTime   time.Time
+
+	usersDone    int64
+	usersSkipped int64
+	bytes        int64
+	status4xx    int64
+	status5xx    int64
+	retries      int64
+}
+
+func newRunStats() *RunStats {
+	return &RunStats{startTime: time.Now()}
+}
+
+func (s *RunStats) addUserDone()     { atomic.AddInt64(&s.usersDone, 1) }
+func (s *RunStats) addUserSkipped()  { atomic.AddInt64(&s.usersSkipped, 1) }
+func (s *RunStats) addBytes(n int64) { atomic.AddInt64(&s.bytes, n) }
+func (s *RunStats) addRetry()        { atomic.AddInt64(&s.retries, 1) }
+func (s *RunStats) finish()          { s.endTime = time.Now() }
+
+func (s *RunStats) addStatus(code int) {
+	switch {
+	case code >= 400 && code < 500:
+		atomic.AddInt64(&s.status4xx, 1)
+	case code >= 500:
+		atomic.AddInt64(&s.status5xx, 1)
+	}
+}
+
+// RunStatsSnapshot is the plain-value form of RunStats, safe to marshal to
+// JSON for --stats-json.
+type RunStatsSnapshot struct {
+	DurationSeconds float64 `json:"durationSeconds"`
+	UsersDone       int64   `json:"usersDone"`
+	UsersSkipped    int64   `json:"usersSkipped"`
+	BytesDownloaded int64   `json:"bytesDownloaded"`
+	Status4xx       int64   `json:"status4xx"`
+	Status5xx       int64   `json:"status5xx"`
+	Retries         int64   `json:"retries"`
+}
+
+func (s *RunStats) Snapshot() RunStatsSnapshot {
+	end := s.endTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return RunStatsSnapshot{
+		DurationSeconds: end.Sub(s.startTime).Seconds(),
+		UsersDone:       atomic.LoadInt64(&s.usersDone),
+		UsersSkipped:    atomic.LoadInt64(&s.usersSkipped),
+		BytesDownloaded: atomic.LoadInt64(&s.bytes),
+		Status4xx:       atomic.LoadInt64(&s.status4xx),
+		Status5xx:       atomic.LoadInt64(&s.status5xx),
+		Retries:         atomic.LoadInt64(&s.retries),
+	}
+}
+
+// Summary renders a one-line, human-readable version of Snapshot for the
+// end-of-run log line.
+func (s *RunStats) Summary() string {
+	snap := s.Snapshot()
+	return fmt.Sprintf(
+		"run summary: users_done=%d users_skipped=%d bytes=%d 4xx=%d 5xx=%d retries=%d duration=%s",
+		snap.UsersDone, snap.UsersSkipped, snap.BytesDownloaded, snap.Status4xx, snap.Status5xx, snap.Retries,
+		time.Duration(snap.DurationSeconds*float64(time.Second)).Round(time.Second),
+	)
+}
+
+// progressBar is a minimal, dependency-free stand-in for a pb.ProgressBar:
+// it prints counts, a completion percentage (when the total is known), a
+// completed-items-per-second rate, and an ETA, refreshed once a second on stderr.
+// It is a no-op when --silent or --no-progress is set, and nil-safe so
+// callers don't need to branch on whether progress reporting is enabled.
+type progressBar struct {
+	label string
+	total int64
+	done  int64
+	start time.Time
+	stop_ chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newProgressBar(label string, total int64) *progressBar {
+	if *silentFlag || *noProgress {
+		return nil
+	}
+	pb := &progressBar{
+		label: label,
+		total: total,
+		start: time.Now(),
+		stop_: make(chan struct{}),
+	}
+	pb.wg.Add(1)
+	go func() {
+		defer pb.wg.Done()
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				pb.render()
+			case <-pb.stop_:
+				pb.render()
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+		}
+	}()
+	return pb
+}
+
+func (pb *progressBar) incDone() {
+	if pb == nil {
+		return
+	}
+	atomic.AddInt64(&pb.done, 1)
+}
+
+func (pb *progressBar) render() {
+	done := atomic.LoadInt64(&pb.done)
+	elapsed := time.Since(pb.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(done) / elapsed
+	}
+	if pb.total > 0 {
+		pct := float64(done) / float64(pb.total) * 100
+		eta := "?"
+		if rate > 0 && done < pb.total {
+			remaining := time.Duration(float64(pb.total-done) / rate * float64(time.Second)).Round(time.Second)
+			eta = remaining.String()
+		} else if done >= pb.total {
+			eta = "0s"
+		}
+		fmt.Fprintf(os.Stderr, "\r%s: %d/%d (%.1f%%) %.1f/s eta=%s   ", pb.label, done, pb.total, pct, rate, eta)
+	} else {
+		fmt.Fprintf(os.Stderr, "\r%s: %d %.1f/s   ", pb.label, done, rate)
+	}
+}
+
+func (pb *progressBar) stop() {
+	if pb == nil {
+		return
+	}
+	close(pb.stop_)
+	pb.wg.Wait()
+}
+
+// ------------------------ serve: S3-compatible read-only HTTP API ------------------------
+
+// runServe exposes a harvested outDir as a single-bucket, read-only
+// S3-compatible endpoint so existing S3 clients (aws s3 ls/cp, s3cmd, rclone)
+// can browse and fetch a Vine archive without any code written specifically
+// against this tool. Every request is verified with AWS SigV4 against a
+// static access-key/secret pair, the same static-credential pattern this
+// tool already uses for R2 ingest.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := fs.String("dir", "vine_archive_harvest", "Local archive directory to expose (profiles/, posts/, media/)")
+	addr := fs.String("addr", ":9000", "Listen address")
+	bucket := fs.String("bucket", "vine-archive", "Virtual bucket name clients must address")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("serve: parse flags: %v", err)
+	}
+
+	accessKey := os.Getenv("SERVE_ACCESS_KEY_ID")
+	secretKey := os.Getenv("SERVE_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatalf("serve: SERVE_ACCESS_KEY_ID and SERVE_SECRET_ACCESS_KEY env vars are required")
+	}
+
+	info, err := os.Stat(*dir)
+	if err != nil || !info.IsDir() {
+		log.Fatalf("serve: %s is not a directory", *dir)
+	}
+
+	srv := &s3Server{dir: *dir, bucket: *bucket, accessKey: accessKey, secretKey: secretKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", srv.authenticate(srv.route))
+
+	log.Printf("Serving %s as s3://%s on %s\n", *dir, *bucket, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// s3Server holds the state needed to answer S3-compatible requests against a
+// local archive directory.
+type s3Server struct {
+	dir       string
+	bucket    string
+	accessKey string
+	secretKey string
+}
+
+func (s *s3Server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := verifySigV4(r, s.accessKey, s.secretKey); err != nil {
+			writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *s3Server) route(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	parts := strings.SplitN(path, "/", 2)
+	if parts[0] != s.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "no such bucket: "+parts[0])
+		return
+	}
+
+	if len(parts) < 2 || parts[1] == "" {
+		if r.URL.Query().Get("list-type") == "2" {
+			s.handleListObjectsV2(w, r)
+			return
+		}
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "no key given")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.handleGetObject(w, r, parts[1], r.Method == http.MethodHead)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "unsupported method "+r.Method)
+	}
+}
+
+func (s *s3Server) handleGetObject(w http.ResponseWriter, r *http.Request, key string, headOnly bool) {
+	localPath, err := keyToLocalPath(s.dir, key)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+		return
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil || info.IsDir() {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "no such key: "+key)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(localPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+
+	if headOnly {
+		return
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	defer f.Close()
+	io.Copy(w, f)
+}
+
+// keyToLocalPath maps an S3 object key onto a path under dir, rejecting any
+// key that would escape dir via ".." traversal.
+func keyToLocalPath(dir, key string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	localPath := filepath.Join(dir, clean)
+	if localPath != dir && !strings.HasPrefix(localPath, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid key: %s", key)
+	}
+	return localPath, nil
+}
+
+// listBucketResult mirrors the subset of the real ListObjectsV2 response
+// shape that S3 clients (aws-cli, s3cmd, rclone) rely on for pagination.
+type listBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	KeyCount              int              `xml:"KeyCount"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	Contents              []s3Content      `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3Content struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (s *s3Server) handleListObjectsV2(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	continuationToken := q.Get("continuation-token")
+
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxKeys = n
+		}
+	}
+
+	var allKeys []string
+	err := filepath.Walk(s.dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return nil
+		}
+		allKeys = append(allKeys, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	sort.Strings(allKeys)
+
+	result := listBucketResult{
+		Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:              s.bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		MaxKeys:           maxKeys,
+		ContinuationToken: continuationToken,
+	}
+
+	seenPrefixes := make(map[string]struct{})
+	var lastIncludedKey string
+	for _, key := range allKeys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if continuationToken != "" && key <= continuationToken {
+			continue
+		}
+
+		if delimiter != "" {
+			rest := key[len(prefix):]
+			if idx := strings.Index(rest, delimiter); idx >= 0 {
+				cp := prefix + rest[:idx+len(delimiter)]
+				if _, ok := seenPrefixes[cp]; !ok {
+					seenPrefixes[cp] = struct{}{}
+					result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: cp})
+				}
+				continue
+			}
+		}
+
+		if result.KeyCount >= maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = lastIncludedKey
+			break
+		}
+
+		localPath := filepath.Join(s.dir, filepath.FromSlash(key))
+		info, err := os.Stat(localPath)
+		size := int64(0)
+		modTime := time.Now().UTC()
+		if err == nil {
+			size = info.Size()
+			modTime = info.ModTime().UTC()
+		}
+		result.Contents = append(result.Contents, s3Content{
+			Key:          key,
+			LastModified: modTime.Format(time.RFC3339),
+			ETag:         `"` + key + `"`,
+			Size:         size,
+			StorageClass: "STANDARD",
+		})
+		result.KeyCount++
+		lastIncludedKey = key
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Encode(result)
+}
+
+// writeS3Error renders the standard S3 XML error body.
+func writeS3Error(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `%s<Error><Code>%s</Code><Message>%s</Message></Error>`,
+		xml.Header, xmlEscape(code), xmlEscape(message))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// ------------------------ AWS SigV4 request verification ------------------------
+
+// verifySigV4 checks a request's "Authorization: AWS4-HMAC-SHA256 ..." header
+// against the given static access-key/secret pair, re-deriving the signature
+// the same way the AWS SDK would have produced it on the client side.
+func verifySigV4(r *http.Request, accessKey, secretKey string) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return fmt.Errorf("missing or unsupported Authorization header")
+	}
+	auth = strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 ")
+
+	fields := map[string]string{}
+	for _, part := range strings.Split(auth, ",") {
+		part = strings.TrimSpace(part)
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	credential := fields["Credential"]
+	signedHeadersRaw := fields["SignedHeaders"]
+	signature := fields["Signature"]
+	if credential == "" || signedHeadersRaw == "" || signature == "" {
+		return fmt.Errorf("malformed Authorization header")
+	}
+
+	credParts := strings.Split(credential, "/")
+	if len(credParts) != 5 {
+		return fmt.Errorf("malformed credential scope")
+	}
+	reqAccessKey, dateStamp, region, service := credParts[0], credParts[1], credParts[2], credParts[3]
+	if reqAccessKey != accessKey {
+		return fmt.Errorf("unknown access key")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		return fmt.Errorf("missing X-Amz-Date header")
+	}
+
+	signedHeaders := strings.Split(signedHeadersRaw, ";")
+	canonicalHeaders, err := canonicalHeadersBlock(r, signedHeaders)
+	if err != nil {
+		return err
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		uriEncodePath(r.URL.Path),
+		canonicalQueryString(r.URL.Query()),
+		canonicalHeaders,
+		signedHeadersRaw,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := strings.Join([]string{dateStamp, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := deriveSigningKey(secretKey, dateStamp, region, service)
+	expected := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
+
+func canonicalHeadersBlock(r *http.Request, signedHeaders []string) (string, error) {
+	var lines []string
+	for _, h := range signedHeaders {
+		var value string
+		if h == "host" {
+			value = r.Host
+		} else {
+			value = r.Header.Get(h)
+		}
+		if value == "" {
+			return "", fmt.Errorf("signed header %q not present on request", h)
+		}
+		lines = append(lines, h+":"+strings.TrimSpace(value))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func canonicalQueryString(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string{}, values[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncodePath percent-encodes a URI path per the SigV4 spec, leaving the
+// path-separating slashes untouched.
+func uriEncodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = uriEncode(seg, false)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEncode implements AWS's URI-encoding rules: percent-encode everything
+// except unreserved characters (A-Za-z0-9-_.~), optionally also encoding '/'.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-' || c == '_' || c == '.' || c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func deriveSigningKey(secretKey, dateStamp, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretKey), []byte(dateStamp))
+	kRegion := hmacSHA256(kDate, []byte(region))
+	kService := hmacSHA256(kRegion, []byte(service))
+	return hmacSHA256(kService, []byte("aws4_request"))
+}
+
+// ------------------------ render: static browsable HTML archive ------------------------
+
+//go:embed templates/*.html
+var siteTemplates embed.FS
+
+// runRender walks a harvested archive directory and writes a self-contained
+// static site (index.html, per-user pages, per-post pages, a JSON sitemap,
+// and a per-user Atom feed) directly alongside its profiles/posts/media, so
+// the result is browsable with nothing more than a file:// URL. It only
+// understands local archives: an s3:// outDir needs syncing down first (e.g.
+// with rclone) before it can be rendered.
+func runRender(args []string) {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	archiveDir := fs.String("outDir", "vine_archive_harvest", "Local archive directory to render (profiles/, posts/, media/)")
+	pageSize := fs.Int("page-size", 60, "Vines per page on each user's grid")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("render: parse flags: %v", err)
+	}
+
+	users, err := loadSiteUsers(*archiveDir)
+	if err != nil {
+		log.Fatalf("render: %v", err)
+	}
+	sort.Slice(users, func(i, j int) bool { return len(users[i].Posts) > len(users[j].Posts) })
+
+	tmpl, err := template.ParseFS(siteTemplates, "templates/*.html")
+	if err != nil {
+		log.Fatalf("render: parse templates: %v", err)
+	}
+
+	sitemap := archiveSitemap{}
+	indexData := siteIndexData{}
+
+	for _, u := range users {
+		if err := renderUserPages(tmpl, *archiveDir, u, *pageSize); err != nil {
+			log.Printf("render: user %s pages: %v\n", u.ID, err)
+			continue
+		}
+		if err := renderPostPages(tmpl, *archiveDir, u); err != nil {
+			log.Printf("render: user %s posts: %v\n", u.ID, err)
+		}
+		if err := writeUserFeed(*archiveDir, u); err != nil {
+			log.Printf("render: user %s feed: %v\n", u.ID, err)
+		}
+
+		indexData.Users = append(indexData.Users, siteUserSummary{
+			ID: u.ID, Username: u.Username(), PostCount: len(u.Posts),
+		})
+
+		postIDs := make([]string, len(u.Posts))
+		for i, p := range u.Posts {
+			postIDs[i] = p.ID
+		}
+		sitemap.Users = append(sitemap.Users, archiveSitemapUser{ID: u.ID, Posts: postIDs})
+	}
+
+	indexPath := filepath.Join(*archiveDir, "index.html")
+	indexF, err := os.Create(indexPath)
+	if err != nil {
+		log.Fatalf("render: create index.html: %v", err)
+	}
+	err = tmpl.ExecuteTemplate(indexF, "index.html", indexData)
+	indexF.Close()
+	if err != nil {
+		log.Fatalf("render: execute index.html: %v", err)
+	}
+
+	if err := writeJSONFile(filepath.Join(*archiveDir, "archive.json"), sitemap); err != nil {
+		log.Fatalf("render: write archive.json: %v", err)
+	}
+
+	log.Printf("Rendered static site for %d users into %s\n", len(users), *archiveDir)
+}
+
+// siteUser is one harvested user's profile plus its posts, loaded off disk
+// for rendering.
+type siteUser struct {
+	ID      string
+	Profile map[string]interface{}
+	Posts   []sitePost
+}
+
+// Username returns the best available display name for a user, falling
+// back to their numeric ID when the profile has neither a username nor a
+// vanity URL.
+func (u siteUser) Username() string {
+	for _, k := range []string{"username", "vanityUrlPath"} {
+		if v, ok := u.Profile[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return u.ID
+}
+
+// Bio, AvatarURL, FollowerCount, FollowingCount, and VanityURL are
+// best-effort, same rationale as postCaption/postCreatedAt below: the
+// archive.vine.co profile JSON isn't a documented schema, so we try a few
+// plausible field names rather than failing rendering over a missing one.
+func (u siteUser) Bio() string {
+	return profileString(u.Profile, "description", "bio")
+}
+
+func (u siteUser) AvatarURL() string {
+	return profileString(u.Profile, "avatarUrl", "avatarUrlSsl")
+}
+
+func (u siteUser) VanityURL() string {
+	return profileString(u.Profile, "vanityUrlPath")
+}
+
+func (u siteUser) FollowerCount() int {
+	return profileInt(u.Profile, "followerCount")
+}
+
+func (u siteUser) FollowingCount() int {
+	return profileInt(u.Profile, "followingCount")
+}
+
+// PostCount is the profile's own self-reported post count, which may
+// differ from len(u.Posts) if the harvest is incomplete.
+func (u siteUser) PostCount() int {
+	return profileInt(u.Profile, "postCount", "vineCount")
+}
+
+func profileString(profile map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := profile[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func profileInt(profile map[string]interface{}, keys ...string) int {
+	for _, k := range keys {
+		if v, ok := profile[k].(float64); ok {
+			return int(v)
+		}
+	}
+	return 0
+}
+
+// sitePost is one harvested post, plus its transcode manifest if --transcode
+// produced one.
+type sitePost struct {
+	ID       string
+	Data     map[string]interface{}
+	Manifest *postManifest
+}
+
+// siteIndexData feeds templates/index.html.
+type siteIndexData struct {
+	Users []siteUserSummary
+}
+
+type siteUserSummary struct {
+	ID        string
+	Username  string
+	PostCount int
+}
+
+// siteUserPageData feeds templates/user.html, one instance per page of a
+// user's grid.
+type siteUserPageData struct {
+	ID             string
+	Username       string
+	Bio            string
+	AvatarURL      string
+	VanityURL      string
+	FollowerCount  int
+	FollowingCount int
+	PostCount      int
+	Posts          []sitePostSummary
+	Page           int
+	TotalPages     int
+	HasPrev        bool
+	HasNext        bool
+	PrevPage       string
+	NextPage       string
+}
+
+type sitePostSummary struct {
+	ID       string
+	Caption  string
+	ThumbKey string
+}
+
+// sitePostPageData feeds templates/post.html.
+type sitePostPageData struct {
+	ID           string
+	UserID       string
+	Username     string
+	Caption      string
+	CreatedAt    string
+	VineURL      string
+	VideoRelPath string
+}
+
+// archiveSitemap is the machine-readable counterpart to index.html, written
+// as archive.json.
+type archiveSitemap struct {
+	Users []archiveSitemapUser `json:"users"`
+}
+
+type archiveSitemapUser struct {
+	ID    string   `json:"id"`
+	Posts []string `json:"posts"`
+}
+
+// loadSiteUsers reads every profiles/<uid>.json under archiveDir and its
+// matching posts/<uid>/*.json, skipping users with no harvested posts.
+func loadSiteUsers(archiveDir string) ([]siteUser, error) {
+	profilesDir := filepath.Join(archiveDir, profilesPrefix)
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil {
+		return nil, fmt.Errorf("read profiles dir: %w", err)
+	}
+
+	var users []siteUser
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		uid := strings.TrimSuffix(e.Name(), ".json")
+
+		raw, err := os.ReadFile(filepath.Join(profilesDir, e.Name()))
+		if err != nil {
+			log.Printf("render: read profile %s: %v\n", uid, err)
+			continue
+		}
+		var profile map[string]interface{}
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			log.Printf("render: decode profile %s: %v\n", uid, err)
+			continue
+		}
+
+		posts, err := loadSitePosts(archiveDir, uid)
+		if err != nil {
+			log.Printf("render: load posts for %s: %v\n", uid, err)
+			continue
+		}
+		if len(posts) == 0 {
+			continue
+		}
+		users = append(users, siteUser{ID: uid, Profile: profile, Posts: posts})
+	}
+	return users, nil
+}
+
+// loadSitePosts reads posts/<uid>/*.json (skipping the *.manifest.json
+// sidecars) and pairs each post with its manifest, if --transcode wrote one.
+func loadSitePosts(archiveDir, uid string) ([]sitePost, error) {
+	dir := filepath.Join(archiveDir, postsPrefix, uid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var posts []sitePost
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".manifest.json") {
+			continue
+		}
+		pid := strings.TrimSuffix(name, ".json")
+
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			continue
+		}
+
+		var manifest *postManifest
+		if manifestRaw, err := os.ReadFile(filepath.Join(dir, pid+".manifest.json")); err == nil {
+			var m postManifest
+			if json.Unmarshal(manifestRaw, &m) == nil {
+				manifest = &m
+			}
+		}
+
+		posts = append(posts, sitePost{ID: pid, Data: data, Manifest: manifest})
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID > posts[j].ID })
+	return posts, nil
+}
+
+// pageFileName maps a 1-based grid page number onto its filename, with page
+// 1 living at index.html so "user/<uid>/" works without a redirect.
+func pageFileName(page int) string {
+	if page <= 1 {
+		return "index.html"
+	}
+	return fmt.Sprintf("page%d.html", page)
+}
+
+func renderUserPages(tmpl *template.Template, archiveDir string, u siteUser, pageSize int) error {
+	userDir := filepath.Join(archiveDir, "user", u.ID)
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return err
+	}
+
+	totalPages := (len(u.Posts) + pageSize - 1) / pageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	for page := 1; page <= totalPages; page++ {
+		start := (page - 1) * pageSize
+		end := start + pageSize
+		if end > len(u.Posts) {
+			end = len(u.Posts)
+		}
+
+		var summaries []sitePostSummary
+		for _, p := range u.Posts[start:end] {
+			summaries = append(summaries, sitePostSummary{
+				ID:       p.ID,
+				Caption:  postCaption(p.Data),
+				ThumbKey: postThumbKey(p),
+			})
+		}
+
+		data := siteUserPageData{
+			ID: u.ID, Username: u.Username(), Posts: summaries,
+			Bio: u.Bio(), AvatarURL: u.AvatarURL(), VanityURL: u.VanityURL(),
+			FollowerCount: u.FollowerCount(), FollowingCount: u.FollowingCount(), PostCount: u.PostCount(),
+			Page: page, TotalPages: totalPages,
+			HasPrev: page > 1, HasNext: page < totalPages,
+		}
+		if data.HasPrev {
+			data.PrevPage = pageFileName(page - 1)
+		}
+		if data.HasNext {
+			data.NextPage = pageFileName(page + 1)
+		}
+
+		f, err := os.Create(filepath.Join(userDir, pageFileName(page)))
+		if err != nil {
+			return err
+		}
+		err = tmpl.ExecuteTemplate(f, "user.html", data)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderPostPages(tmpl *template.Template, archiveDir string, u siteUser) error {
+	postDir := filepath.Join(archiveDir, "post")
+	if err := os.MkdirAll(postDir, 0755); err != nil {
+		return err
+	}
+
+	for _, p := range u.Posts {
+		data := sitePostPageData{
+			ID:           p.ID,
+			UserID:       u.ID,
+			Username:     u.Username(),
+			Caption:      postCaption(p.Data),
+			CreatedAt:    postCreatedAt(p.Data),
+			VineURL:      "https://vine.co/v/" + p.ID,
+			VideoRelPath: postVideoRelPath(p),
+		}
+
+		f, err := os.Create(filepath.Join(postDir, p.ID+".html"))
+		if err != nil {
+			return err
+		}
+		err = tmpl.ExecuteTemplate(f, "post.html", data)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postCaption and postCreatedAt are best-effort: the archive.vine.co post
+// JSON isn't a documented schema, so we try a few plausible field names
+// rather than failing rendering over a missing caption.
+func postCaption(data map[string]interface{}) string {
+	for _, k := range []string{"description", "caption", "text"} {
+		if v, ok := data[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func postCreatedAt(data map[string]interface{}) string {
+	for _, k := range []string{"created", "createdAt", "created_at"} {
+		if v, ok := data[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// postThumbKey returns the archive-root-relative path to a post's
+// thumbnail, if --transcode produced one.
+func postThumbKey(p sitePost) string {
+	if p.Manifest == nil {
+		return ""
+	}
+	for _, v := range p.Manifest.Transcodes {
+		if v.Kind == "thumbnail" {
+			return v.Key
+		}
+	}
+	return ""
+}
+
+// postVideoRelPath returns the archive-root-relative path to the best
+// available copy of a post's video: the normalized H.264 transcode if
+// --transcode ran, otherwise the original downloaded media.
+func postVideoRelPath(p sitePost) string {
+	if p.Manifest != nil {
+		for _, v := range p.Manifest.Transcodes {
+			if v.Kind == "h264" {
+				return v.Key
+			}
+		}
+		if p.Manifest.MediaKey != "" {
+			return p.Manifest.MediaKey
+		}
+	}
+	for _, mu := range collectMediaURLs(p.Data) {
+		if !strings.Contains(mu, ".mp4") {
+			continue
+		}
+		if key, err := mediaKeyForURL(mu); err == nil {
+			return key
+		}
+	}
+	return ""
+}
+
+// atomFeed and atomEntry are a minimal Atom 1.0 feed, written per user so
+// the archive can be followed in a feed reader.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+func writeUserFeed(archiveDir string, u siteUser) error {
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Title:   u.Username() + " on Vine",
+		ID:      "urn:vine-archive:user:" + u.ID,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, p := range u.Posts {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   postCaption(p.Data),
+			ID:      "urn:vine-archive:post:" + p.ID,
+			Link:    atomLink{Href: "../../post/" + p.ID + ".html"},
+			Updated: postCreatedAt(p.Data),
+		})
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(archiveDir, "user", u.ID, "feed.xml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
-	return os.Rename(tmp, localPath)
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
 }